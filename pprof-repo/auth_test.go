@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedUploadRequest(t *testing.T, token, name string, timestamp time.Time, body []byte) (*Project, []byte, string) {
+	t.Helper()
+
+	project := &Project{UploadToken: token, replayCache: newReplayCache()}
+
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(token))
+	timestampHeader := strconv.FormatInt(timestamp.Unix(), 10)
+	fmt.Fprintf(mac, "%s\n%s\n%s", timestampHeader, name, hex.EncodeToString(bodyHash[:]))
+
+	return project, body, hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthenticateUploadPlainToken(t *testing.T) {
+	project := &Project{UploadToken: "s3cret", replayCache: newReplayCache()}
+
+	r := httptest.NewRequest("POST", "/upload?project=p", nil)
+	r.Header.Set("x-upload-token", "s3cret")
+	if err := authenticateUpload(project, r, nil); err != nil {
+		t.Fatalf("expected matching token to authenticate, got: %v", err)
+	}
+
+	r = httptest.NewRequest("POST", "/upload?project=p", nil)
+	r.Header.Set("x-upload-token", "wrong")
+	if err := authenticateUpload(project, r, nil); err == nil {
+		t.Fatal("expected mismatched token to be rejected")
+	}
+}
+
+func TestAuthenticateHMAC(t *testing.T) {
+	body := []byte("binary-contents")
+	project, body, signature := signedUploadRequest(t, "s3cret", "foo", time.Now(), body)
+
+	r := httptest.NewRequest("POST", "/upload?project=p&name=foo", nil)
+	r.Header.Set("X-Upload-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	r.Header.Set("X-Upload-Signature", signature)
+	if err := authenticateUpload(project, r, body); err != nil {
+		t.Fatalf("expected valid signature to authenticate, got: %v", err)
+	}
+}
+
+func TestAuthenticateHMACRejectsBadSignature(t *testing.T) {
+	body := []byte("binary-contents")
+	project, body, _ := signedUploadRequest(t, "s3cret", "foo", time.Now(), body)
+
+	r := httptest.NewRequest("POST", "/upload?project=p&name=foo", nil)
+	r.Header.Set("X-Upload-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	r.Header.Set("X-Upload-Signature", "not-the-right-signature")
+	if err := authenticateUpload(project, r, body); err == nil {
+		t.Fatal("expected invalid signature to be rejected")
+	}
+}
+
+func TestAuthenticateHMACRejectsStaleTimestamp(t *testing.T) {
+	body := []byte("binary-contents")
+	old := time.Now().Add(-2 * maxSignatureAge)
+	project, body, signature := signedUploadRequest(t, "s3cret", "foo", old, body)
+
+	r := httptest.NewRequest("POST", "/upload?project=p&name=foo", nil)
+	r.Header.Set("X-Upload-Timestamp", strconv.FormatInt(old.Unix(), 10))
+	r.Header.Set("X-Upload-Signature", signature)
+	if err := authenticateUpload(project, r, body); err == nil {
+		t.Fatal("expected stale timestamp to be rejected")
+	}
+}
+
+func TestAuthenticateHMACRejectsReplay(t *testing.T) {
+	body := []byte("binary-contents")
+	project, body, signature := signedUploadRequest(t, "s3cret", "foo", time.Now(), body)
+
+	r := httptest.NewRequest("POST", "/upload?project=p&name=foo", nil)
+	r.Header.Set("X-Upload-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	r.Header.Set("X-Upload-Signature", signature)
+	if err := authenticateUpload(project, r, body); err != nil {
+		t.Fatalf("expected first use of signature to authenticate, got: %v", err)
+	}
+
+	r2 := httptest.NewRequest("POST", "/upload?project=p&name=foo", nil)
+	r2.Header.Set("X-Upload-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	r2.Header.Set("X-Upload-Signature", signature)
+	if err := authenticateUpload(project, r2, body); err == nil {
+		t.Fatal("expected replayed signature to be rejected")
+	}
+}
+
+func TestReplayCacheObserve(t *testing.T) {
+	c := newReplayCache()
+	base := time.Unix(1700000000, 0)
+
+	if !c.observe("sig-a", base) {
+		t.Fatal("expected first observation of sig-a to be new")
+	}
+	if c.observe("sig-a", base.Add(time.Minute)) {
+		t.Fatal("expected replayed sig-a to be rejected")
+	}
+	if !c.observe("sig-a", base.Add(maxSignatureAge+time.Minute)) {
+		t.Fatal("expected sig-a to be forgotten once it aged out")
+	}
+}
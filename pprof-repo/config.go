@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type RetentionConfig struct {
+	MaxProfiles   int    `json:"max_profiles" yaml:"max_profiles"`
+	MaxAge        string `json:"max_age" yaml:"max_age"`
+	MaxTotalBytes int64  `json:"max_total_bytes" yaml:"max_total_bytes"`
+}
+
+type ProjectConfig struct {
+	Name        string          `json:"name" yaml:"name"`
+	UploadToken string          `json:"upload_token" yaml:"upload_token"`
+	ProfilesDir string          `json:"profiles_dir" yaml:"profiles_dir"`
+	BinariesDir string          `json:"binaries_dir" yaml:"binaries_dir"`
+	Store       StoreConfig     `json:"store" yaml:"store"`
+	Retention   RetentionConfig `json:"retention" yaml:"retention"`
+	Targets     []TargetConfig  `json:"targets" yaml:"targets"`
+}
+
+// StoreConfig selects the ProfileStore backend a project's profiles are
+// kept in. Type defaults to "local", storing profiles under ProfilesDir;
+// "s3" and "gcs" store them in the given Bucket under Prefix instead.
+type StoreConfig struct {
+	Type   string `json:"type" yaml:"type"`
+	Bucket string `json:"bucket" yaml:"bucket"`
+	Prefix string `json:"prefix" yaml:"prefix"`
+}
+
+// TargetConfig describes a pprof endpoint to scrape on a fixed interval,
+// e.g. http://svc:6060/debug/pprof/profile?seconds=30.
+type TargetConfig struct {
+	Name        string            `json:"name" yaml:"name"`
+	URL         string            `json:"url" yaml:"url"`
+	ProfileType string            `json:"profile_type" yaml:"profile_type"`
+	Interval    string            `json:"interval" yaml:"interval"`
+	Timeout     string            `json:"timeout" yaml:"timeout"`
+	Headers     map[string]string `json:"headers" yaml:"headers"`
+}
+
+type Config struct {
+	Projects []ProjectConfig `json:"projects" yaml:"projects"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, config)
+	default:
+		err = json.Unmarshal(data, config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if len(config.Projects) == 0 {
+		return nil, fmt.Errorf("config has no projects")
+	}
+	seen := map[string]bool{}
+	for i, p := range config.Projects {
+		if p.Name == "" {
+			return nil, fmt.Errorf("projects[%d] has no name", i)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("duplicate project name %q", p.Name)
+		}
+		seen[p.Name] = true
+		if p.UploadToken == "" {
+			return nil, fmt.Errorf("project %s: upload_token is required", p.Name)
+		}
+		if config.Projects[i].ProfilesDir == "" {
+			config.Projects[i].ProfilesDir = filepath.Join("profiles", p.Name)
+		}
+		if config.Projects[i].BinariesDir == "" {
+			config.Projects[i].BinariesDir = filepath.Join("binaries", p.Name)
+		}
+		if config.Projects[i].Store.Type != "" && config.Projects[i].Store.Type != "local" && config.Projects[i].Store.Bucket == "" {
+			return nil, fmt.Errorf("project %s: store.bucket is required for store.type %q", p.Name, config.Projects[i].Store.Type)
+		}
+		if config.Projects[i].Store.Prefix == "" {
+			config.Projects[i].Store.Prefix = p.Name
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/pprof/driver"
+	"github.com/google/pprof/profile"
+)
+
+// diffBuild tracks the one-time build of a single diffID, so two concurrent
+// requests for the same base/sample pair don't both call ProfileMux.Handle
+// with the same pattern (which panics).
+type diffBuild struct {
+	once sync.Once
+	err  error
+}
+
+// LoadDiff builds (or reuses) a diff profile between base and sample,
+// equivalent to `go tool pprof -base base sample`, and returns the profile
+// ID it was registered under within p.ProfileMux.
+func (p *Project) LoadDiff(base, sample string) (string, error) {
+	diffID := fmt.Sprintf("diff-%s-vs-%s", base, sample)
+
+	p.mu.Lock()
+	build, ok := p.diffs[diffID]
+	if !ok {
+		build = &diffBuild{}
+		p.diffs[diffID] = build
+	}
+	p.mu.Unlock()
+
+	build.once.Do(func() {
+		build.err = p.buildDiff(diffID, base, sample)
+	})
+
+	if build.err != nil {
+		// Don't memoize failures: only a successfully registered diff is
+		// safe to keep serving from p.diffs forever. Drop this attempt so
+		// the next request rebuilds from scratch, but only if nothing else
+		// has already replaced it with a newer attempt.
+		p.mu.Lock()
+		if p.diffs[diffID] == build {
+			delete(p.diffs, diffID)
+		}
+		p.mu.Unlock()
+	}
+
+	return diffID, build.err
+}
+
+func (p *Project) buildDiff(diffID, base, sample string) error {
+	baseProfile, ok := p.rawProfile(base)
+	if !ok {
+		return fmt.Errorf("unknown profile %q", base)
+	}
+	sampleProfile, ok := p.rawProfile(sample)
+	if !ok {
+		return fmt.Errorf("unknown profile %q", sample)
+	}
+
+	diffProfile, err := mergeDiff(baseProfile, sampleProfile)
+	if err != nil {
+		return err
+	}
+
+	prefix := fmt.Sprintf("/profiles/%s/%s", p.Name, diffID)
+	options := &driver.Options{
+		Fetch:   &fetcher{pprof: diffProfile},
+		Obj:     p.objTool,
+		Flagset: new(flagSet),
+		UI:      new(ui),
+		HTTPServer: func(ha *driver.HTTPServerArgs) error {
+			for handlerPath, handler := range ha.Handlers {
+				p.ProfileMux.Handle(prefix+handlerPath, handler)
+			}
+			return nil
+		},
+	}
+	return driver.PProf(options)
+}
+
+// mergeDiff negates base's sample values and merges it with sample, the
+// same trick `pprof -base` uses to produce a diff profile.
+func mergeDiff(base, sample *profile.Profile) (*profile.Profile, error) {
+	base = base.Copy()
+	for _, s := range base.Sample {
+		for i := range s.Value {
+			s.Value[i] = -s.Value[i]
+		}
+	}
+
+	return profile.Merge([]*profile.Profile{base, sample.Copy()})
+}
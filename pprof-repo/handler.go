@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ProfileInfo struct {
+	Name    string
+	ModTime time.Time
+	Size    int64
+}
+
+// Handler dispatches across the configured projects (tenants). Routes are
+// shared by all projects: /upload?project=X and /profiles/X/...
+type Handler struct {
+	Projects map[string]*Project
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	switch r.URL.Path {
+	case "/":
+		switch r.Method {
+		case http.MethodGet:
+			h.index(w, r)
+		}
+	case "/upload":
+		switch r.Method {
+		case http.MethodPost:
+			h.upload(w, r)
+		}
+	case "/diff":
+		switch r.Method {
+		case http.MethodGet:
+			h.diff(w, r)
+		}
+	case "/binaries":
+		switch r.Method {
+		case http.MethodPost:
+			h.uploadBinary(w, r)
+		}
+	default:
+		if strings.HasPrefix(r.URL.Path, "/profiles/") {
+			h.profiles(w, r)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "<html><body>")
+	for _, name := range sortedProjectNames(h.Projects) {
+		project := h.Projects[name]
+		fmt.Fprintf(w, "<h3>%s</h3>", name)
+		for _, profile := range project.Profiles {
+			fmt.Fprintf(w, `<div><a href="/profiles/%s/%s">%s (%s)</a></div>`, name, profile.Name, profile.Name, profile.ModTime.Format(time.RFC3339))
+		}
+
+		fmt.Fprintf(w, `<form action="/diff" method="get">`)
+		fmt.Fprintf(w, `<input type="hidden" name="project" value="%s">`, name)
+		fmt.Fprintf(w, `base: <select name="base">`)
+		for _, profile := range project.Profiles {
+			fmt.Fprintf(w, `<option value="%s">%s</option>`, profile.Name, profile.Name)
+		}
+		fmt.Fprintf(w, `</select> sample: <select name="sample">`)
+		for _, profile := range project.Profiles {
+			fmt.Fprintf(w, `<option value="%s">%s</option>`, profile.Name, profile.Name)
+		}
+		fmt.Fprintf(w, `</select> <button type="submit">diff</button></form>`)
+	}
+	fmt.Fprintf(w, "</body></html>\n")
+}
+
+var profilesRegexp = regexp.MustCompile(`^/profiles/([a-zA-Z0-9_\-]+)(/.*)?$`)
+
+func (h *Handler) profiles(w http.ResponseWriter, r *http.Request) {
+	m := profilesRegexp.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	project, ok := h.Projects[m[1]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	project.ProfileMux.ServeHTTP(w, r)
+}
+
+var profileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.Projects[r.FormValue("project")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	profileName := r.FormValue("name")
+	if !profileNameRegexp.MatchString(profileName) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("pprof")
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(file); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := authenticateUpload(project, r, buf.Bytes()); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := project.Store.Put(profileName+".pprof", bytes.NewReader(buf.Bytes())); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := project.LoadProfile(profileName, bytes.NewReader(buf.Bytes())); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "/profiles/%s/%s\n", project.Name, profileName)
+}
+
+func (h *Handler) diff(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.Projects[r.FormValue("project")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	base := r.FormValue("base")
+	sample := r.FormValue("sample")
+	if !profileNameRegexp.MatchString(base) || !profileNameRegexp.MatchString(sample) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	diffID, err := project.LoadDiff(base, sample)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/profiles/%s/%s/", project.Name, diffID), http.StatusFound)
+}
+
+var buildIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+func (h *Handler) uploadBinary(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.Projects[r.FormValue("project")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	buildID := r.FormValue("build_id")
+	if !buildIDRegexp.MatchString(buildID) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("binary")
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(file); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !isELFOrMachO(buf.Bytes()) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := project.StoreBinary(buildID, bytes.NewReader(buf.Bytes())); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// machoMagics lists the four byte orderings/widths debug/macho recognizes
+// as a Mach-O header (32/64-bit, native/reversed endian); fat (universal)
+// binaries use a fifth, separate magic.
+var machoMagics = [][]byte{
+	{0xfe, 0xed, 0xfa, 0xce},
+	{0xce, 0xfa, 0xed, 0xfe},
+	{0xfe, 0xed, 0xfa, 0xcf},
+	{0xcf, 0xfa, 0xed, 0xfe},
+}
+
+// isELFOrMachO reports whether data starts with an ELF or Mach-O magic
+// number, so uploadBinary rejects anything localObjTool.Open could never
+// symbolize.
+func isELFOrMachO(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	if bytes.Equal(data[:4], []byte{0x7f, 'E', 'L', 'F'}) {
+		return true
+	}
+	for _, magic := range machoMagics {
+		if bytes.Equal(data[:4], magic) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedProjectNames(projects map[string]*Project) []string {
+	names := make([]string, 0, len(projects))
+	for name := range projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"debug/macho"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/google/pprof/driver"
+)
+
+// localObjTool resolves mappings against binaries previously uploaded to
+// POST /binaries and stored under BinariesDir, keyed by build ID. It
+// satisfies driver.ObjTool without shelling out to addr2line/objdump,
+// supporting both ELF and Mach-O executables.
+type localObjTool struct {
+	binariesDir string
+}
+
+func newLocalObjTool(binariesDir string) *localObjTool {
+	return &localObjTool{binariesDir: binariesDir}
+}
+
+func (o *localObjTool) Open(file string, start, limit, offset uint64, relocationSymbol string) (driver.ObjFile, error) {
+	path, err := o.resolve(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if elfFile, elfErr := elf.Open(path); elfErr == nil {
+		return newELFObjFile(file, path, elfFile, start, offset)
+	}
+
+	if machoFile, machoErr := macho.Open(path); machoErr == nil {
+		return newMachoObjFile(file, path, machoFile, start, offset)
+	}
+
+	return nil, fmt.Errorf("%s is neither a valid ELF nor Mach-O binary", path)
+}
+
+// resolve maps the file argument the driver passes to Open to a path under
+// BinariesDir. loadProfile rewrites the File of each mapping that has a
+// BuildID to that BuildID before handing the profile to the driver, so for
+// those mappings file is the build ID, not the path the profile was
+// originally recorded against (which may not even exist on this host).
+// A mapping without a BuildID keeps its recorded path, which is used as is
+// if it exists on this host.
+func (o *localObjTool) resolve(file string) (string, error) {
+	candidate := filepath.Join(o.binariesDir, file)
+	if _, err := os.Stat(candidate); err == nil {
+		return candidate, nil
+	}
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+	return "", fmt.Errorf("no uploaded binary found for build ID %q", file)
+}
+
+func (o *localObjTool) Disasm(file string, start, end uint64, intelSyntax bool) ([]driver.Inst, error) {
+	return nil, fmt.Errorf("disassembly is not supported")
+}
+
+// localObjFile is a driver.ObjFile backed by either an ELF or a Mach-O
+// file; the two constructors below do the format-specific work of finding
+// the Go symbol table and the runtime load bias, after which every method
+// is format-agnostic.
+type localObjFile struct {
+	name   string
+	path   string
+	base   uint64
+	closer io.Closer
+	table  *gosym.Table
+}
+
+func newELFObjFile(name, path string, f *elf.File, start, offset uint64) (*localObjFile, error) {
+	table, err := elfGoSymTable(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	base, err := elfLoadBias(f, start, offset)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &localObjFile{name: name, path: path, base: base, closer: f, table: table}, nil
+}
+
+func newMachoObjFile(name, path string, f *macho.File, start, offset uint64) (*localObjFile, error) {
+	table, err := machoGoSymTable(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	base, err := machoLoadBias(f, start, offset)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &localObjFile{name: name, path: path, base: base, closer: f, table: table}, nil
+}
+
+func elfGoSymTable(f *elf.File) (*gosym.Table, error) {
+	pclntab := f.Section(".gopclntab")
+	text := f.Section(".text")
+	if pclntab == nil || text == nil {
+		return nil, fmt.Errorf("binary has no .gopclntab/.text section")
+	}
+
+	pclntabData, err := pclntab.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	var symtabData []byte
+	if symtab := f.Section(".gosymtab"); symtab != nil {
+		symtabData, _ = symtab.Data()
+	}
+
+	lineTable := gosym.NewLineTable(pclntabData, text.Addr)
+	return gosym.NewTable(symtabData, lineTable)
+}
+
+func machoGoSymTable(f *macho.File) (*gosym.Table, error) {
+	pclntab := f.Section("__gopclntab")
+	text := f.Section("__text")
+	if pclntab == nil || text == nil {
+		return nil, fmt.Errorf("binary has no __gopclntab/__text section")
+	}
+
+	pclntabData, err := pclntab.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	var symtabData []byte
+	if symtab := f.Section("__gosymtab"); symtab != nil {
+		symtabData, _ = symtab.Data()
+	}
+
+	lineTable := gosym.NewLineTable(pclntabData, text.Addr)
+	return gosym.NewTable(symtabData, lineTable)
+}
+
+// elfLoadBias returns the difference between where this mapping was
+// actually loaded at runtime (start) and the address the binary itself was
+// linked for, i.e. what must be subtracted from a runtime address before
+// looking it up in the binary's own symbol/line tables. Non-PIE (ET_EXEC)
+// binaries load at their link address, so the bias is always 0 for them;
+// PIE (ET_DYN) binaries load at a kernel-chosen address, so the bias is
+// derived from the PT_LOAD segment covering this mapping's file offset.
+func elfLoadBias(f *elf.File, start, offset uint64) (uint64, error) {
+	if f.Type == elf.ET_EXEC {
+		return 0, nil
+	}
+
+	var first *elf.Prog
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_LOAD {
+			continue
+		}
+		if first == nil {
+			first = prog
+		}
+		if offset >= prog.Off && offset < prog.Off+prog.Filesz {
+			return start - (prog.Vaddr + (offset - prog.Off)), nil
+		}
+	}
+	if first != nil {
+		return start - first.Vaddr, nil
+	}
+	return 0, fmt.Errorf("binary has no PT_LOAD segments")
+}
+
+// machoLoadBias is elfLoadBias's Mach-O equivalent: Mach-O executables are
+// always MH_EXECUTE regardless of whether they're position-independent, so
+// PIE-ness is signaled by the MH_PIE flag instead of a distinct file type.
+func machoLoadBias(f *macho.File, start, offset uint64) (uint64, error) {
+	if f.Flags&macho.FlagPIE == 0 {
+		return 0, nil
+	}
+
+	var first *macho.Segment
+	for _, load := range f.Loads {
+		seg, ok := load.(*macho.Segment)
+		if !ok || seg.Filesz == 0 {
+			continue
+		}
+		if first == nil {
+			first = seg
+		}
+		if offset >= seg.Offset && offset < seg.Offset+seg.Filesz {
+			return start - (seg.Addr + (offset - seg.Offset)), nil
+		}
+	}
+	if first != nil {
+		return start - first.Addr, nil
+	}
+	return 0, fmt.Errorf("binary has no loadable segments")
+}
+
+func (f *localObjFile) Name() string {
+	return f.name
+}
+
+func (f *localObjFile) Base() uint64 {
+	return f.base
+}
+
+// ObjAddr translates a runtime (mapped) address back to the address space
+// of the object file itself, mirroring binutils.file.ObjAddr.
+func (f *localObjFile) ObjAddr(addr uint64) (uint64, error) {
+	return addr - f.base, nil
+}
+
+func (f *localObjFile) BuildID() string {
+	return filepath.Base(f.path)
+}
+
+func (f *localObjFile) SourceLine(addr uint64) ([]driver.Frame, error) {
+	objAddr, err := f.ObjAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	file, line, fn := f.table.PCToLine(objAddr)
+	if fn == nil {
+		return nil, nil
+	}
+	return []driver.Frame{{Func: fn.Name, File: file, Line: line}}, nil
+}
+
+func (f *localObjFile) Symbols(r *regexp.Regexp, addr uint64) ([]*driver.Sym, error) {
+	var objAddr uint64
+	if addr != 0 {
+		var err error
+		objAddr, err = f.ObjAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var syms []*driver.Sym
+	for _, fn := range f.table.Funcs {
+		if r != nil && !r.MatchString(fn.Name) {
+			continue
+		}
+		if addr != 0 && (objAddr < fn.Entry || objAddr >= fn.End) {
+			continue
+		}
+		syms = append(syms, &driver.Sym{
+			Name:  []string{fn.Name},
+			File:  f.path,
+			Start: fn.Entry,
+			End:   fn.End,
+		})
+	}
+	return syms, nil
+}
+
+func (f *localObjFile) Close() error {
+	return f.closer.Close()
+}
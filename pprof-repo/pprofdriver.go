@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+type fetcher struct {
+	pprof *profile.Profile
+}
+
+func (f *fetcher) Fetch(src string, duration, timeout time.Duration) (*profile.Profile, string, error) {
+	return f.pprof, "", nil
+}
+
+type flagSet struct{}
+
+func (s *flagSet) Bool(name string, def bool, usage string) *bool {
+	var v bool
+	return &v
+}
+func (s *flagSet) Int(name string, def int, usage string) *int {
+	var v int
+	return &v
+}
+func (s *flagSet) Float64(name string, def float64, usage string) *float64 {
+	var v float64 = 1
+	return &v
+}
+func (s *flagSet) String(name string, def string, usage string) *string {
+	if name == "http" {
+		v := "0.0.0.0:0"
+		return &v
+	}
+	var v string
+	return &v
+}
+func (s *flagSet) StringList(name string, def string, usage string) *[]*string {
+	var v []*string
+	return &v
+}
+func (s *flagSet) ExtraUsage() string {
+	return ""
+}
+
+func (s *flagSet) AddExtraUsage(eu string) {
+}
+
+func (s *flagSet) Parse(usage func()) []string {
+	return []string{"-http", "0.0.0.0:0"}
+}
+
+type ui struct{}
+
+func (*ui) ReadLine(prompt string) (string, error)       { return "", nil }
+func (*ui) Print(...interface{})                         {}
+func (*ui) PrintErr(...interface{})                      {}
+func (*ui) IsTerminal() bool                             { return false }
+func (*ui) WantBrowser() bool                            { return false }
+func (*ui) SetAutoComplete(complete func(string) string) {}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/pprof/driver"
+	"github.com/google/pprof/profile"
+)
+
+// Project is a single tenant: its own upload token, profile store and
+// retention policy, plus the profiles it has loaded so far.
+type Project struct {
+	Name        string
+	UploadToken string
+	BinariesDir string
+	Store       ProfileStore
+	Retention   RetentionConfig
+
+	maxAge      time.Duration
+	targets     []*scrapeTarget
+	objTool     *localObjTool
+	replayCache *replayCache
+
+	mu          sync.Mutex
+	ProfileMux  *http.ServeMux
+	Profiles    []ProfileInfo
+	rawProfiles map[string]*profile.Profile
+	diffs       map[string]*diffBuild
+}
+
+func NewProject(config ProjectConfig) (*Project, error) {
+	var maxAge time.Duration
+	if config.Retention.MaxAge != "" {
+		d, err := time.ParseDuration(config.Retention.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("project %s: invalid retention.max_age: %w", config.Name, err)
+		}
+		maxAge = d
+	}
+
+	store, err := newProfileStore(config)
+	if err != nil {
+		return nil, fmt.Errorf("project %s: %w", config.Name, err)
+	}
+
+	if err := os.MkdirAll(config.BinariesDir, 0777); err != nil {
+		return nil, err
+	}
+
+	targets := make([]*scrapeTarget, 0, len(config.Targets))
+	for _, targetConfig := range config.Targets {
+		target, err := newScrapeTarget(targetConfig)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+
+	p := &Project{
+		Name:        config.Name,
+		UploadToken: config.UploadToken,
+		BinariesDir: config.BinariesDir,
+		Store:       store,
+		Retention:   config.Retention,
+		maxAge:      maxAge,
+		targets:     targets,
+		objTool:     newLocalObjTool(config.BinariesDir),
+		replayCache: newReplayCache(),
+		ProfileMux:  http.NewServeMux(),
+		rawProfiles: map[string]*profile.Profile{},
+		diffs:       map[string]*diffBuild{},
+	}
+
+	infos, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if err := p.loadStoredProfile(info); err != nil {
+			log.Printf("project %s: failed to load profile %s: %v", p.Name, info.Name, err)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Project) loadStoredProfile(info ProfileInfo) error {
+	r, err := p.Store.Get(info.Name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	profileID := strings.TrimSuffix(info.Name, filepath.Ext(info.Name))
+	return p.loadProfile(profileID, r, info.ModTime)
+}
+
+// LoadProfile parses and registers a profile that has already been written
+// to the store under "<profileID>.pprof".
+func (p *Project) LoadProfile(profileID string, r io.Reader) error {
+	return p.loadProfile(profileID, r, time.Now())
+}
+
+func (p *Project) loadProfile(profileID string, r io.Reader, modTime time.Time) error {
+	pprof, err := profile.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	// The driver's Obj.Open is called with each mapping's recorded File,
+	// which is whatever path the profile was captured against and may not
+	// exist on this host. Rewrite it to the mapping's BuildID so localObjTool
+	// can resolve it against a binary uploaded via POST /binaries.
+	for _, m := range pprof.Mapping {
+		if m.BuildID != "" {
+			m.File = m.BuildID
+		}
+	}
+
+	prefix := fmt.Sprintf("/profiles/%s/%s", p.Name, profileID)
+	options := &driver.Options{
+		Fetch:   &fetcher{pprof: pprof},
+		Obj:     p.objTool,
+		Flagset: new(flagSet),
+		UI:      new(ui),
+		HTTPServer: func(ha *driver.HTTPServerArgs) error {
+			for handlerPath, handler := range ha.Handlers {
+				p.ProfileMux.Handle(prefix+handlerPath, handler)
+			}
+			return nil
+		},
+	}
+	if err := driver.PProf(options); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Profiles = append(p.Profiles, ProfileInfo{
+		Name:    profileID,
+		ModTime: modTime,
+	})
+	sort.Slice(p.Profiles, func(i, j int) bool {
+		return p.Profiles[i].ModTime.After(p.Profiles[j].ModTime)
+	})
+	p.rawProfiles[profileID] = pprof
+
+	return nil
+}
+
+// StoreBinary saves an uploaded ELF/Mach-O executable under BinariesDir,
+// keyed by its build ID, so it can later be used to symbolize profiles
+// whose mappings reference that build ID.
+func (p *Project) StoreBinary(buildID string, r io.Reader) error {
+	path := filepath.Join(p.BinariesDir, buildID)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (p *Project) rawProfile(profileID string) (*profile.Profile, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pprof, ok := p.rawProfiles[profileID]
+	return pprof, ok
+}
+
+func (p *Project) removeProfile(profileID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	profiles := p.Profiles[:0]
+	for _, info := range p.Profiles {
+		if info.Name != profileID {
+			profiles = append(profiles, info)
+		}
+	}
+	p.Profiles = profiles
+	delete(p.rawProfiles, profileID)
+}
+
+// startReaper runs reap on the given interval until the process exits.
+func (p *Project) startReaper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := p.reap(); err != nil {
+				log.Printf("project %s: reaper failed: %v", p.Name, err)
+			}
+		}
+	}()
+}
+
+func (p *Project) reap() error {
+	if p.Retention.MaxProfiles <= 0 && p.maxAge <= 0 && p.Retention.MaxTotalBytes <= 0 {
+		return nil
+	}
+
+	infos, err := p.Store.List()
+	if err != nil {
+		return err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime.After(infos[j].ModTime)
+	})
+
+	now := time.Now()
+	var totalBytes int64
+	for i, info := range infos {
+		totalBytes += info.Size
+		expired := p.maxAge > 0 && now.Sub(info.ModTime) > p.maxAge
+		overCount := p.Retention.MaxProfiles > 0 && i >= p.Retention.MaxProfiles
+		overBytes := p.Retention.MaxTotalBytes > 0 && totalBytes > p.Retention.MaxTotalBytes
+		if !expired && !overCount && !overBytes {
+			continue
+		}
+
+		if err := p.Store.Delete(info.Name); err != nil {
+			log.Printf("project %s: failed to remove profile %s: %v", p.Name, info.Name, err)
+			continue
+		}
+		p.removeProfile(strings.TrimSuffix(info.Name, filepath.Ext(info.Name)))
+	}
+
+	return nil
+}
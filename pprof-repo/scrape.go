@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultScrapeInterval = 30 * time.Second
+	defaultScrapeTimeout  = 60 * time.Second
+
+	// scrapeTimeoutBuffer is added on top of a CPU profile's "seconds"
+	// duration to get a default timeout, so the request itself has time to
+	// complete after the profiler stops collecting.
+	scrapeTimeoutBuffer = 30 * time.Second
+)
+
+var validProfileTypes = map[string]bool{
+	"":          true,
+	"cpu":       true,
+	"heap":      true,
+	"goroutine": true,
+	"allocs":    true,
+}
+
+// scrapeTarget is a parsed, ready-to-run TargetConfig.
+type scrapeTarget struct {
+	name        string
+	url         string
+	profileType string
+	headers     map[string]string
+	interval    time.Duration
+	timeout     time.Duration
+}
+
+func newScrapeTarget(config TargetConfig) (*scrapeTarget, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("target is missing a name")
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("target %s is missing a url", config.Name)
+	}
+	if !validProfileTypes[config.ProfileType] {
+		return nil, fmt.Errorf("target %s: unknown profile_type %q", config.Name, config.ProfileType)
+	}
+
+	interval := defaultScrapeInterval
+	if config.Interval != "" {
+		d, err := time.ParseDuration(config.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: invalid interval: %w", config.Name, err)
+		}
+		interval = d
+	}
+
+	timeout := defaultScrapeTimeout
+	if config.ProfileType == "cpu" {
+		// A CPU profile blocks the scrape for its "seconds" duration (the
+		// URL's own, or the interval scrapeURL fills in) before it even
+		// starts responding, so the flat default timeout isn't enough once
+		// that duration exceeds it.
+		duration := interval
+		if u, err := url.Parse(config.URL); err == nil {
+			if s, err := strconv.Atoi(u.Query().Get("seconds")); err == nil {
+				duration = time.Duration(s) * time.Second
+			}
+		}
+		timeout = duration + scrapeTimeoutBuffer
+	}
+	if config.Timeout != "" {
+		d, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: invalid timeout: %w", config.Name, err)
+		}
+		timeout = d
+	}
+
+	return &scrapeTarget{
+		name:        config.Name,
+		url:         config.URL,
+		profileType: config.ProfileType,
+		headers:     config.Headers,
+		interval:    interval,
+		timeout:     timeout,
+	}, nil
+}
+
+// startScraping launches one polling goroutine per configured target.
+func (p *Project) startScraping() {
+	for _, t := range p.targets {
+		go p.scrapeLoop(t)
+	}
+}
+
+func (p *Project) scrapeLoop(t *scrapeTarget) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.scrapeOnce(t); err != nil {
+			log.Printf("project %s: scrape %s failed: %v", p.Name, t.name, err)
+		}
+		<-ticker.C
+	}
+}
+
+func (p *Project) scrapeOnce(t *scrapeTarget) error {
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.scrapeURL(), nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("scraping %s: unexpected status %s", t.url, resp.Status)
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+
+	profileID := fmt.Sprintf("%s-%d", sanitizeTargetName(t.name), time.Now().Unix())
+	if err := p.Store.Put(profileID+".pprof", bytes.NewReader(buf.Bytes())); err != nil {
+		return err
+	}
+
+	return p.LoadProfile(profileID, bytes.NewReader(buf.Bytes()))
+}
+
+// scrapeURL is t.url, with a "seconds" query param derived from the scrape
+// interval added for CPU profiles that don't already specify a duration
+// (heap/goroutine/allocs profiles are instantaneous and need no duration).
+func (t *scrapeTarget) scrapeURL() string {
+	if t.profileType != "cpu" {
+		return t.url
+	}
+
+	u, err := url.Parse(t.url)
+	if err != nil {
+		return t.url
+	}
+
+	q := u.Query()
+	if q.Get("seconds") == "" {
+		q.Set("seconds", strconv.Itoa(int(t.interval.Seconds())))
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+var targetNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+func sanitizeTargetName(name string) string {
+	return targetNameSanitizer.ReplaceAllString(name, "_")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ProfileStore is where a project's uploaded/scraped .pprof files live.
+// Implementations only ever see the bare file name, e.g. "foo.pprof".
+type ProfileStore interface {
+	Put(name string, r io.Reader) error
+	Get(name string) (io.ReadCloser, error)
+	List() ([]ProfileInfo, error)
+	Delete(name string) error
+}
+
+func newProfileStore(config ProjectConfig) (ProfileStore, error) {
+	switch config.Store.Type {
+	case "", "local":
+		return newLocalStore(config.ProfilesDir)
+	case "s3":
+		awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("loading AWS config: %w", err)
+		}
+		return newS3Store(s3.NewFromConfig(awsCfg), config.Store.Bucket, config.Store.Prefix), nil
+	case "gcs":
+		client, err := storage.NewClient(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("creating GCS client: %w", err)
+		}
+		return newGCSStore(client, config.Store.Bucket, config.Store.Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown store type %q", config.Store.Type)
+	}
+}
+
+type localStore struct {
+	dir string
+}
+
+func newLocalStore(dir string) (*localStore, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &localStore{dir: dir}, nil
+}
+
+func (s *localStore) Put(name string, r io.Reader) error {
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (s *localStore) Get(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, name))
+}
+
+func (s *localStore) List() ([]ProfileInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []ProfileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pprof" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, ProfileInfo{Name: entry.Name(), ModTime: info.ModTime(), Size: info.Size()})
+	}
+	return infos, nil
+}
+
+func (s *localStore) Delete(name string) error {
+	return os.Remove(filepath.Join(s.dir, name))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+type gcsStore struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+func newGCSStore(client *storage.Client, bucket, prefix string) *gcsStore {
+	return &gcsStore{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *gcsStore) object(name string) *storage.ObjectHandle {
+	return s.client.Bucket(s.bucket).Object(path.Join(s.prefix, name))
+}
+
+func (s *gcsStore) Put(name string, r io.Reader) error {
+	w := s.object(name).NewWriter(context.Background())
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *gcsStore) Get(name string) (io.ReadCloser, error) {
+	return s.object(name).NewReader(context.Background())
+}
+
+func (s *gcsStore) List() ([]ProfileInfo, error) {
+	prefix := s.prefix + "/"
+	it := s.client.Bucket(s.bucket).Objects(context.Background(), &storage.Query{Prefix: prefix})
+
+	var infos []ProfileInfo
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(attrs.Name, prefix)
+		if filepath.Ext(name) != ".pprof" {
+			continue
+		}
+		infos = append(infos, ProfileInfo{Name: name, ModTime: attrs.Updated, Size: attrs.Size})
+	}
+	return infos, nil
+}
+
+func (s *gcsStore) Delete(name string) error {
+	return s.object(name).Delete(context.Background())
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleConfig is one watch rule: watch Glob for changes and, once quiescent
+// for Debounce, run Command with Args (both support the {{.Path}} template
+// substitution).
+type RuleConfig struct {
+	Name           string   `yaml:"name"`
+	Glob           string   `yaml:"glob"`
+	Debounce       string   `yaml:"debounce"`
+	Cooldown       string   `yaml:"cooldown"`
+	Command        string   `yaml:"command"`
+	Args           []string `yaml:"args"`
+	MaxConcurrency int      `yaml:"max_concurrency"`
+}
+
+type Config struct {
+	Rules []RuleConfig `yaml:"rules"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("config has no rules")
+	}
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rules[%d] has no name", i)
+		}
+		if rule.Glob == "" {
+			return nil, fmt.Errorf("rule %s has no glob", rule.Name)
+		}
+		if rule.Command == "" {
+			return nil, fmt.Errorf("rule %s has no command", rule.Name)
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const pollInterval = time.Second
+
+// Engine dispatches filesystem events to whichever rules match them.
+type Engine struct {
+	rules []*Rule
+}
+
+func NewEngine(rules []*Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Run watches for filesystem changes and triggers matching rules until the
+// process exits. It prefers fsnotify and falls back to polling once a
+// second when a watch can't be set up (e.g. the filesystem has no inotify
+// support).
+func (e *Engine) Run() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("fsnotify unavailable (%v), falling back to polling", err)
+		return e.runPolling()
+	}
+	defer watcher.Close()
+
+	dirs := map[string]bool{}
+	for _, rule := range e.rules {
+		dirs[filepath.Dir(rule.Glob)] = true
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			log.Printf("watching %s failed (%v), falling back to polling", dir, err)
+			return e.runPolling()
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			e.dispatch(event.Name)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("watcher error: %v", err)
+		}
+	}
+}
+
+func (e *Engine) dispatch(path string) {
+	for _, rule := range e.rules {
+		if rule.Matches(path) {
+			rule.Trigger(path)
+		}
+	}
+}
+
+// runPolling is the fallback path for filesystems fsnotify can't watch; it
+// globs each rule's pattern once a second and triggers on ModTime changes.
+func (e *Engine) runPolling() error {
+	modTimes := map[string]time.Time{}
+	for _, rule := range e.rules {
+		matches, err := filepath.Glob(rule.Glob)
+		if err != nil {
+			log.Printf("rule %s: invalid glob: %v", rule.Name, err)
+			continue
+		}
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil {
+				modTimes[path] = info.ModTime()
+			}
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, rule := range e.rules {
+			matches, err := filepath.Glob(rule.Glob)
+			if err != nil {
+				log.Printf("rule %s: invalid glob: %v", rule.Name, err)
+				continue
+			}
+			for _, path := range matches {
+				info, err := os.Stat(path)
+				if err != nil {
+					continue
+				}
+				if modTimes[path] != info.ModTime() {
+					modTimes[path] = info.ModTime()
+					rule.Trigger(path)
+				}
+			}
+		}
+	}
+	return nil
+}
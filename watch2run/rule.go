@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const defaultDebounce = time.Second
+
+// Rule is a parsed, ready-to-run RuleConfig. It debounces events for a
+// given path and runs at most MaxConcurrency commands concurrently.
+type Rule struct {
+	Name     string
+	Glob     string
+	Debounce time.Duration
+	Cooldown time.Duration
+	Command  *template.Template
+	Args     []*template.Template
+
+	sem chan struct{}
+
+	mu        sync.Mutex
+	timers    map[string]*time.Timer
+	lastRunAt map[string]time.Time
+}
+
+func NewRule(config RuleConfig) (*Rule, error) {
+	debounce := defaultDebounce
+	if config.Debounce != "" {
+		d, err := time.ParseDuration(config.Debounce)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: invalid debounce: %w", config.Name, err)
+		}
+		debounce = d
+	}
+
+	var cooldown time.Duration
+	if config.Cooldown != "" {
+		d, err := time.ParseDuration(config.Cooldown)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: invalid cooldown: %w", config.Name, err)
+		}
+		cooldown = d
+	}
+
+	command, err := template.New(config.Name + "-command").Parse(config.Command)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: invalid command template: %w", config.Name, err)
+	}
+
+	args := make([]*template.Template, len(config.Args))
+	for i, arg := range config.Args {
+		tmpl, err := template.New(fmt.Sprintf("%s-arg%d", config.Name, i)).Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: invalid arg template: %w", config.Name, err)
+		}
+		args[i] = tmpl
+	}
+
+	maxConcurrency := config.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+
+	return &Rule{
+		Name:      config.Name,
+		Glob:      config.Glob,
+		Debounce:  debounce,
+		Cooldown:  cooldown,
+		Command:   command,
+		Args:      args,
+		sem:       make(chan struct{}, maxConcurrency),
+		timers:    map[string]*time.Timer{},
+		lastRunAt: map[string]time.Time{},
+	}, nil
+}
+
+// Matches reports whether path satisfies the rule's glob pattern, tried
+// both against the full path and the base name.
+func (r *Rule) Matches(path string) bool {
+	if ok, _ := filepath.Match(r.Glob, path); ok {
+		return true
+	}
+	ok, _ := filepath.Match(r.Glob, filepath.Base(path))
+	return ok
+}
+
+// Trigger coalesces bursts of events for path within the debounce window,
+// running the rule's command only once path has been quiet that long.
+func (r *Rule) Trigger(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if timer, ok := r.timers[path]; ok {
+		timer.Stop()
+	}
+	r.timers[path] = time.AfterFunc(r.Debounce, func() {
+		r.run(path)
+	})
+}
+
+func (r *Rule) run(path string) {
+	r.mu.Lock()
+	if r.Cooldown > 0 {
+		if lastRunAt, ok := r.lastRunAt[path]; ok && time.Since(lastRunAt) < r.Cooldown {
+			r.mu.Unlock()
+			return
+		}
+	}
+	r.lastRunAt[path] = time.Now()
+	delete(r.timers, path)
+	r.mu.Unlock()
+
+	select {
+	case r.sem <- struct{}{}:
+	default:
+		log.Printf("rule %s: max concurrency reached, dropping run for %s", r.Name, path)
+		return
+	}
+	defer func() { <-r.sem }()
+
+	data := struct{ Path string }{Path: path}
+
+	var commandBuf bytes.Buffer
+	if err := r.Command.Execute(&commandBuf, data); err != nil {
+		log.Printf("rule %s: command template: %v", r.Name, err)
+		return
+	}
+
+	args := make([]string, len(r.Args))
+	for i, tmpl := range r.Args {
+		var argBuf bytes.Buffer
+		if err := tmpl.Execute(&argBuf, data); err != nil {
+			log.Printf("rule %s: arg template: %v", r.Name, err)
+			return
+		}
+		args[i] = argBuf.String()
+	}
+
+	log.Printf("rule %s: running %s %v", r.Name, commandBuf.String(), args)
+	cmd := exec.Command(commandBuf.String(), args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Printf("rule %s: command failed: %v", r.Name, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func countRuns(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return len(strings.Fields(string(data)))
+}
+
+func TestRuleTriggerDebouncesBurstsIntoOneRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	rule, err := NewRule(RuleConfig{
+		Name:     "debounce",
+		Glob:     "*",
+		Debounce: "30ms",
+		Command:  "/bin/sh",
+		Args:     []string{"-c", "echo 1 >> " + out},
+	})
+	if err != nil {
+		t.Fatalf("NewRule: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		rule.Trigger("/tmp/watched")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if runs := countRuns(t, out); runs != 1 {
+		t.Fatalf("expected a burst of Trigger calls to debounce into 1 run, got %d", runs)
+	}
+}
+
+func TestRuleRunRespectsCooldown(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	rule, err := NewRule(RuleConfig{
+		Name:     "cooldown",
+		Glob:     "*",
+		Cooldown: "1h",
+		Command:  "/bin/sh",
+		Args:     []string{"-c", "echo 1 >> " + out},
+	})
+	if err != nil {
+		t.Fatalf("NewRule: %v", err)
+	}
+
+	rule.run("/tmp/watched")
+	rule.run("/tmp/watched")
+
+	if runs := countRuns(t, out); runs != 1 {
+		t.Fatalf("expected the second run within the cooldown window to be skipped, got %d runs", runs)
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	rule, err := NewRule(RuleConfig{Name: "match", Glob: "*.go", Command: "/bin/true"})
+	if err != nil {
+		t.Fatalf("NewRule: %v", err)
+	}
+
+	if !rule.Matches("/src/main.go") {
+		t.Fatal("expected /src/main.go to match *.go")
+	}
+	if rule.Matches("/src/main.txt") {
+		t.Fatal("expected /src/main.txt not to match *.go")
+	}
+}